@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	tensorflow "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// Classifier runs inference over raw image bytes and returns all labels
+// sorted by descending probability.
+type Classifier interface {
+	Labels(img []byte) ([]Label, error)
+}
+
+// BatchClassifier is implemented by Classifiers that can run inference over
+// several images in a single session.Run call, stacking them into the
+// BATCH_SIZE dimension the model graph already supports.
+type BatchClassifier interface {
+	ClassifyBatch(images [][]byte) ([][]Label, error)
+}
+
+// ModelConfig describes the preprocessing and graph op names a model
+// backend expects. It is configurable per deployment via LoadModelConfig
+// so swapping in a different model (e.g. NASNet-Mobile or MobileNet)
+// never requires a recompile.
+type ModelConfig struct {
+	InputSize int      `yaml:"inputSize"`
+	Mean      float32  `yaml:"mean"`
+	Scale     float32  `yaml:"scale"`
+	InputOp   string   `yaml:"inputOp"`
+	OutputOp  string   `yaml:"outputOp"`
+	Tags      []string `yaml:"tags"`
+}
+
+// DefaultInceptionConfig matches the frozen tensorflow_inception_graph.pb
+// shipped with this repo.
+var DefaultInceptionConfig = ModelConfig{
+	InputSize: 224,
+	Mean:      117,
+	Scale:     1,
+	InputOp:   "input",
+	OutputOp:  "output",
+}
+
+// LoadModelConfig reads a YAML file describing a model's preprocessing
+// parameters (inputSize, mean, scale, inputOp, outputOp, tags), falling
+// back to DefaultInceptionConfig when path is empty. Fields absent from
+// the file keep their DefaultInceptionConfig value.
+func LoadModelConfig(path string) (ModelConfig, error) {
+	if path == "" {
+		return DefaultInceptionConfig, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ModelConfig{}, err
+	}
+
+	config := DefaultInceptionConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return ModelConfig{}, err
+	}
+	return config, nil
+}
+
+// LoadClassifier picks a backend based on the contents of modelDir: a
+// directory containing saved_model.pb is loaded as a TensorFlow SavedModel
+// (e.g. NASNet-Mobile or MobileNet), anything else falls back to the
+// legacy frozen-graph Inception loader, expecting a single *.pb file.
+func LoadClassifier(modelDir, labelsFile string, config ModelConfig) (Classifier, error) {
+	if isSavedModelDir(modelDir) {
+		return NewSavedModelClassifier(modelDir, labelsFile, config)
+	}
+	return NewInceptionClassifier(modelDir, labelsFile, config)
+}
+
+// isSavedModelDir reports whether modelDir looks like a TensorFlow
+// SavedModel export (contains saved_model.pb) rather than a single frozen
+// *.pb graph.
+func isSavedModelDir(modelDir string) bool {
+	_, err := os.Stat(filepath.Join(modelDir, "saved_model.pb"))
+	return err == nil
+}
+
+// graphRunner is implemented by backends that expose a TensorFlow graph +
+// session pair under config.InputOp/OutputOp: both the frozen-graph
+// Inception loader and the SavedModel loader satisfy it. It lets
+// classifyOne/classifyBatch be shared across backends instead of
+// duplicated.
+type graphRunner interface {
+	run(tensor *tensorflow.Tensor) (*tensorflow.Tensor, error)
+}
+
+// classifyOne normalizes a single image and runs it through r, returning
+// all labels sorted by descending probability.
+func classifyOne(r graphRunner, preprocessor *Preprocessor, labels []string, img []byte) ([]Label, error) {
+	tensor, err := preprocessor.Normalize(bytes.NewReader(img))
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := r.run(tensor)
+	if err != nil {
+		return nil, err
+	}
+
+	return getTopLabels(labels, output.Value().([][]float32)[0], len(labels)), nil
+}
+
+// classifyBatch stacks all images into a single tensor and runs them
+// through r in one call, instead of once per image.
+func classifyBatch(r graphRunner, preprocessor *Preprocessor, labels []string, images [][]byte) ([][]Label, error) {
+	readers := make([]io.Reader, len(images))
+	for i, img := range images {
+		readers[i] = bytes.NewReader(img)
+	}
+
+	tensor, err := preprocessor.NormalizeBatch(readers)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := r.run(tensor)
+	if err != nil {
+		return nil, err
+	}
+
+	probabilities := output.Value().([][]float32)
+	results := make([][]Label, len(probabilities))
+	for i, p := range probabilities {
+		results[i] = getTopLabels(labels, p, len(labels))
+	}
+	return results, nil
+}
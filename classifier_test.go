@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsSavedModelDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "classifier-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if isSavedModelDir(dir) {
+		t.Fatalf("isSavedModelDir(%q) = true before saved_model.pb exists", dir)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "saved_model.pb"), nil, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if !isSavedModelDir(dir) {
+		t.Fatalf("isSavedModelDir(%q) = false after saved_model.pb exists", dir)
+	}
+}
@@ -0,0 +1,45 @@
+// Package imgutil provides a pure-Go image decode + resize shared by the
+// main classifier's Preprocessor fallback path and the nsfw subpackage,
+// so formats without a TensorFlow graph op (BMP, WebP) are only handled
+// in one place.
+package imgutil
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/webp"
+)
+
+func init() {
+	image.RegisterFormat("bmp", "BM", bmp.Decode, bmp.DecodeConfig)
+	image.RegisterFormat("webp", "RIFF????WEBP", webp.Decode, webp.DecodeConfig)
+}
+
+// DecodeAndResize decodes raw in pure Go, correcting EXIF orientation, and
+// resizes it to size x size. It returns the RGB pixel grid as [0,255]
+// range float32 triples ([H][W][3]); callers apply their own mean/scale
+// normalization on top.
+func DecodeAndResize(raw []byte, size int) ([][][3]float32, error) {
+	img, err := imaging.Decode(bytes.NewReader(raw), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image: %v", err)
+	}
+
+	resized := imaging.Resize(img, size, size, imaging.Lanczos)
+	bounds := resized.Bounds()
+
+	pixels := make([][][3]float32, bounds.Dy())
+	for y := 0; y < bounds.Dy(); y++ {
+		pixels[y] = make([][3]float32, bounds.Dx())
+		for x := 0; x < bounds.Dx(); x++ {
+			r, g, b, _ := resized.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			pixels[y][x] = [3]float32{float32(r >> 8), float32(g >> 8), float32(b >> 8)}
+		}
+	}
+
+	return pixels, nil
+}
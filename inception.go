@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	tensorflow "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// inceptionClassifier runs inference against a frozen-graph model such as
+// the classic tensorflow_inception_graph.pb. session.Run is not safe for
+// concurrent use, so calls are serialized through mu.
+type inceptionClassifier struct {
+	graph        *tensorflow.Graph
+	session      *tensorflow.Session
+	labels       []string
+	config       ModelConfig
+	preprocessor *Preprocessor
+
+	mu sync.Mutex
+}
+
+// NewInceptionClassifier loads the single frozen *.pb graph found in
+// modelDir and the newline-delimited labelsFile.
+func NewInceptionClassifier(modelDir, labelsFile string, config ModelConfig) (*inceptionClassifier, error) {
+	pbFiles, err := filepath.Glob(filepath.Join(modelDir, "*.pb"))
+	if err != nil {
+		return nil, err
+	}
+	if len(pbFiles) != 1 {
+		return nil, fmt.Errorf("expected exactly one *.pb file in %s, found %d", modelDir, len(pbFiles))
+	}
+
+	model, err := ioutil.ReadFile(pbFiles[0])
+	if err != nil {
+		return nil, err
+	}
+
+	graph := tensorflow.NewGraph()
+	if err := graph.Import(model, ""); err != nil {
+		return nil, err
+	}
+
+	labels, err := loadLabels(labelsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := tensorflow.NewSession(graph, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inceptionClassifier{
+		graph:        graph,
+		session:      session,
+		labels:       labels,
+		config:       config,
+		preprocessor: NewPreprocessor(config),
+	}, nil
+}
+
+func (c *inceptionClassifier) Labels(img []byte) ([]Label, error) {
+	return classifyOne(c, c.preprocessor, c.labels, img)
+}
+
+// ClassifyBatch stacks all images into a single [N,H,W,3] tensor and runs
+// inference once, instead of once per image.
+func (c *inceptionClassifier) ClassifyBatch(images [][]byte) ([][]Label, error) {
+	return classifyBatch(c, c.preprocessor, c.labels, images)
+}
+
+func (c *inceptionClassifier) run(tensor *tensorflow.Tensor) (*tensorflow.Tensor, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	output, err := c.session.Run(
+		map[tensorflow.Output]*tensorflow.Tensor{
+			c.graph.Operation(c.config.InputOp).Output(0): tensor,
+		},
+		[]tensorflow.Output{
+			c.graph.Operation(c.config.OutputOp).Output(0),
+		},
+		nil)
+	if err != nil {
+		return nil, err
+	}
+	return output[0], nil
+}
+
+func loadLabels(labelsFile string) ([]string, error) {
+	f, err := os.Open(labelsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	var labels []string
+	for scanner.Scan() {
+		labels = append(labels, scanner.Text())
+	}
+	return labels, scanner.Err()
+}
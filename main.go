@@ -1,29 +1,29 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"sort"
+	"strings"
 
-	tensorflow "github.com/tensorflow/tensorflow/tensorflow/go"
-	"github.com/tensorflow/tensorflow/tensorflow/go/op"
+	"github.com/fedproject/go_img_recognition/nsfw"
 )
 
 const (
-	graphFile  = "/model/tensorflow_inception_graph.pb"
-	labelsFile = "/model/imagenet_comp_graph_label_strings.txt"
+	modelDir     = "/model"
+	labelsFile   = "/model/imagenet_comp_graph_label_strings.txt"
+	nsfwModelDir = "/model/nsfw"
 )
 
 // Label type
 type Label struct {
-	Label       string  `json:"label"`
-	Probability float32 `json:"probability"`
+	Label       string   `json:"label"`
+	Probability float32  `json:"probability"`
+	Categories  []string `json:"categories,omitempty"`
 }
 
 // Labels type
@@ -37,162 +37,198 @@ func main() {
 	os.Setenv("TF_CPP_MIN_LOG_LEVEL", "2")
 
 	if len(os.Args) < 2 {
-		log.Fatalf("usage: imgrecognition <image_url>")
+		log.Fatalf("usage: imgrecognition [--rules labels.yml] [--model-config model.yml] <image_url> | imgrecognition serve --addr :8080 [--rules labels.yml] [--model-config model.yml]")
 	}
-	fmt.Printf("url: %s\n", os.Args[1])
 
-	// Get image from URL
-	response, e := http.Get(os.Args[1])
-	if e != nil {
-		log.Fatalf("unable to get image from url: %v", e)
+	if os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
 	}
-	defer response.Body.Close()
 
-	// unable to load graph and labels
-	modelGraph, labels, err := loadModel()
+	runCLI(os.Args[1:])
+}
+
+// runCLI classifies one or more images given as URLs or local file paths.
+// A single image uses Classifier.Labels; several images are classified in
+// one batched session.Run via BatchClassifier.
+func runCLI(args []string) {
+	fs := flag.NewFlagSet("imgrecognition", flag.ExitOnError)
+	rulesPath := fs.String("rules", "", "path to a labels.yml rules file")
+	modelConfigPath := fs.String("model-config", "", "path to a YAML file overriding the model's preprocessing params (inputSize, mean, scale, inputOp, outputOp, tags)")
+	runNSFW := fs.Bool("nsfw", false, "also run NSFW detection")
+	fs.Parse(args)
+
+	sources := fs.Args()
+	if len(sources) == 0 {
+		log.Fatalf("usage: imgrecognition [--rules labels.yml] [--model-config model.yml] [--nsfw] <image_url_or_path> [image_url_or_path...]")
+	}
+
+	rules, err := loadRules(*rulesPath)
 	if err != nil {
-		log.Fatalf("unable to load model: %v", err)
+		log.Fatalf("unable to load rules: %v", err)
 	}
 
-	// Get normalized tensor     get normalized tensor ready
-	tensor, err := normalizeImage(response.Body)
+	modelConfig, err := LoadModelConfig(*modelConfigPath)
 	if err != nil {
-		log.Fatalf("unable to make a tensor from image: %v", err)
+		log.Fatalf("unable to load model config: %v", err)
 	}
 
-	// Create a session for inference over modelGraph   get session ready
-	session, err := tensorflow.NewSession(modelGraph, nil)
+	classifier, err := LoadClassifier(modelDir, labelsFile, modelConfig)
 	if err != nil {
-		log.Fatalf("could not init session: %v", err)
+		log.Fatalf("unable to load model: %v", err)
 	}
 
-	//defer session.Close()
+	var detector *nsfw.Detector
+	if *runNSFW {
+		detector, err = nsfw.NewDetector(nsfwModelDir)
+		if err != nil {
+			log.Fatalf("unable to load nsfw model: %v", err)
+		}
+	}
 
-	output, err := session.Run(
-		map[tensorflow.Output]*tensorflow.Tensor{
-			modelGraph.Operation("input").Output(0): tensor, // use input from modelGraph
-		},
-		[]tensorflow.Output{
-			modelGraph.Operation("output").Output(0),
-		},
-		nil)
-	if err != nil {
-		log.Fatalf("could not run inference: %v", err) // unable to inference
+	images := make([][]byte, len(sources))
+	for i, src := range sources {
+		fmt.Printf("image: %s\n", src)
+		img, err := fetchImage(src)
+		if err != nil {
+			log.Fatalf("unable to read image %s: %v", src, err)
+		}
+		images[i] = img
+	}
+
+	if len(images) == 1 {
+		labels, err := classifier.Labels(images[0])
+		if err != nil {
+			log.Fatalf("could not run inference: %v", err)
+		}
+		printLabels(sources[0], rules.Classify(labels))
+		printNSFWLabels(detector, images[0])
+		return
 	}
 
-	// list top the probabilities and assign labels
-	res := getTopFiveLabels(labels, output[0].Value().([][]float32)[0])
-	for _, l := range res {
-		fmt.Printf("label: %s, probability: %.2f%%\n", l.Label, l.Probability*100) // show percentage
+	batch, ok := classifier.(BatchClassifier)
+	if !ok {
+		log.Fatalf("classifier does not support batch inference")
 	}
-}
 
-func loadModel() (*tensorflow.Graph, []string, error) {
-	// Load inception model
-	model, err := ioutil.ReadFile(graphFile)
+	results, err := batch.ClassifyBatch(images)
 	if err != nil {
-		return nil, nil, err
+		log.Fatalf("could not run batch inference: %v", err)
 	}
-	// Load Graph
-	graph := tensorflow.NewGraph()
-	if err := graph.Import(model, ""); err != nil {
-		return nil, nil, err
+	for i, labels := range results {
+		printLabels(sources[i], rules.Classify(labels))
+		printNSFWLabels(detector, images[i])
+	}
+}
+
+// printNSFWLabels runs the NSFW detector over img and prints its scores, a
+// no-op when detector is nil (i.e. --nsfw was not passed).
+func printNSFWLabels(detector *nsfw.Detector, img []byte) {
+	if detector == nil {
+		return
 	}
 
-	// Load labels
-	labelsFile, err := os.Open(labelsFile)
+	labels, err := detector.Labels(img)
 	if err != nil {
-		return nil, nil, err
+		log.Printf("nsfw: could not run inference: %v", err)
+		return
 	}
-	defer labelsFile.Close()
-	scanner := bufio.NewScanner(labelsFile)
-	var labels []string
-	for scanner.Scan() {
-		labels = append(labels, scanner.Text())
+	for _, l := range labels {
+		fmt.Printf("nsfw: %s, probability: %.2f%%\n", l.Label, l.Probability*100)
 	}
-
-	return graph, labels, scanner.Err()
 }
 
-func getTopFiveLabels(labels []string, probabilities []float32) []Label {
-	var resultLabels []Label
-	for i, p := range probabilities {
-		if i >= len(labels) {
-			break // break condition
+// fetchImage reads an image from an http(s) URL or a local file path.
+func fetchImage(source string) ([]byte, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		response, err := http.Get(source)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get image from url: %v", err)
 		}
-		resultLabels = append(resultLabels, Label{Label: labels[i], Probability: p})
+		defer response.Body.Close()
+		return ioutil.ReadAll(response.Body)
 	}
-	// create
-	// type Label struct{
-	// 	Label      string
-	//      Proability float32
-	// }
-	// func (l Labels) Len() int {return len(l)}
-	// func (l Labels) Swap() int {return len(l)}
-	// func (l Labels) Less() bool {l[i].Probability < l[j].Probability }
-
-	sort.Sort(Labels(resultLabels))
-	return resultLabels[:5]
+	return ioutil.ReadFile(source)
 }
 
-func normalizeImage(body io.ReadCloser) (*tensorflow.Tensor, error) {
-	var buf bytes.Buffer
-	io.Copy(&buf, body)
+func printLabels(source string, labels []Label) {
+	fmt.Printf("--- %s ---\n", source)
+	for _, l := range topLabels(labels, 5) {
+		fmt.Printf("label: %s, probability: %.2f%%\n", l.Label, l.Probability*100)
+	}
+}
 
-	tensor, err := tensorflow.NewTensor(buf.String())
+// runServe loads the model once and serves /recognize and /healthz until
+// killed.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	rulesPath := fs.String("rules", "", "path to a labels.yml rules file")
+	modelConfigPath := fs.String("model-config", "", "path to a YAML file overriding the model's preprocessing params (inputSize, mean, scale, inputOp, outputOp, tags)")
+	runNSFW := fs.Bool("nsfw", false, "also run NSFW detection alongside the main classifier")
+	fs.Parse(args)
+
+	rules, err := loadRules(*rulesPath)
 	if err != nil {
-		return nil, err
+		log.Fatalf("unable to load rules: %v", err)
 	}
 
-	graph, input, output, err := getNormalizedGraph()
+	modelConfig, err := LoadModelConfig(*modelConfigPath)
 	if err != nil {
-		return nil, err
+		log.Fatalf("unable to load model config: %v", err)
 	}
 
-	// set graph
-	session, err := tensorflow.NewSession(graph, nil)
+	classifier, err := LoadClassifier(modelDir, labelsFile, modelConfig)
 	if err != nil {
-		return nil, err
-	}
-	// normalized, err
-	// normalized: a slice of tensors
-	normalized, err := session.Run(
-		map[tensorflow.Output]*tensorflow.Tensor{
-			input: tensor,
-		},
-		[]tensorflow.Output{
-			output,
-		},
-		nil)
-	if err != nil {
-		return nil, err
+		log.Fatalf("unable to load model: %v", err)
+	}
+
+	var detector *nsfw.Detector
+	if *runNSFW {
+		detector, err = nsfw.NewDetector(nsfwModelDir)
+		if err != nil {
+			log.Fatalf("unable to load nsfw model: %v", err)
+		}
+	}
+
+	srv := NewServer(classifier, rules, detector)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/recognize", srv.handleRecognize)
+	mux.HandleFunc("/healthz", srv.handleHealthz)
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// loadRules loads a LabelRules file, or returns an empty (no-op) ruleset
+// when path is empty.
+func loadRules(path string) (LabelRules, error) {
+	if path == "" {
+		return LabelRules{}, nil
+	}
+	return LoadLabelRules(path)
+}
+
+// getTopLabels pairs raw model probabilities with their labels and returns
+// the topN sorted by descending probability.
+func getTopLabels(labels []string, probabilities []float32, topN int) []Label {
+	var resultLabels []Label
+	for i, p := range probabilities {
+		if i >= len(labels) {
+			break
+		}
+		resultLabels = append(resultLabels, Label{Label: labels[i], Probability: p})
 	}
 
-	// normalized image, and return it to the main func?
-	return normalized[0], nil
+	sort.Sort(Labels(resultLabels))
+	return topLabels(resultLabels, topN)
 }
 
-// NORMALIZE IMG to a specific Inception format: 1. normalize, resize, set constraints
-// Creates a graph to decode, rezise and normalize an image
-func getNormalizedGraph() (graph *tensorflow.Graph, input, output tensorflow.Output, err error) {
-	s := op.NewScope()
-	input = op.Placeholder(s, tensorflow.String)
-	// 3 return RGB image
-	decode := op.DecodeJpeg(s, input, op.DecodeJpegChannels(3))
-
-	// Sub: returns x - y element-wise
-	output = op.Sub(s,
-		// make it 224x224: inception specific
-		op.ResizeBilinear(s,
-			// inserts a dimension of 1 into a tensor's shape.
-			op.ExpandDims(s,
-				// cast image to float type
-				op.Cast(s, decode, tensorflow.Float),
-				op.Const(s.SubScope("make_batch"), int32(0))),
-			op.Const(s.SubScope("size"), []int32{224, 224})),
-		// mean = 117: inception specific
-		op.Const(s.SubScope("mean"), float32(117)))
-	graph, err = s.Finalize()
-
-	return graph, input, output, err
+// topLabels trims an already-sorted []Label down to topN entries.
+func topLabels(labels []Label, topN int) []Label {
+	if topN > len(labels) {
+		topN = len(labels)
+	}
+	return labels[:topN]
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetTopLabels(t *testing.T) {
+	labels := []string{"cat", "dog", "bird"}
+	probabilities := []float32{0.2, 0.9, 0.5}
+
+	got := getTopLabels(labels, probabilities, 2)
+
+	want := []Label{
+		{Label: "dog", Probability: 0.9},
+		{Label: "bird", Probability: 0.5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("getTopLabels() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetTopLabelsIgnoresExtraProbabilities(t *testing.T) {
+	labels := []string{"cat"}
+	probabilities := []float32{0.2, 0.9}
+
+	got := getTopLabels(labels, probabilities, 5)
+
+	want := []Label{{Label: "cat", Probability: 0.2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("getTopLabels() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTopLabelsClampsToLength(t *testing.T) {
+	labels := []Label{{Label: "cat"}, {Label: "dog"}}
+
+	got := topLabels(labels, 10)
+
+	if !reflect.DeepEqual(got, labels) {
+		t.Fatalf("topLabels() = %+v, want %+v", got, labels)
+	}
+}
@@ -0,0 +1,109 @@
+// Package nsfw scores images for not-safe-for-work content using a
+// Yahoo Open-NSFW compatible SavedModel.
+package nsfw
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/fedproject/go_img_recognition/imgutil"
+
+	tensorflow "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+const inputSize = 224
+
+// categories are the NSFW classes the Yahoo Open-NSFW / nsfw_model
+// SavedModel was trained to score.
+var categories = []string{"drawing", "hentai", "neutral", "porn", "sexy"}
+
+// Label holds a single NSFW category score.
+type Label struct {
+	Label       string  `json:"label"`
+	Probability float32 `json:"probability"`
+}
+
+// Labels type
+type Labels []Label
+
+func (a Labels) Len() int           { return len(a) }
+func (a Labels) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a Labels) Less(i, j int) bool { return a[i].Probability > a[j].Probability }
+
+// Detector runs a SavedModel NSFW classifier. session.Run is not safe for
+// concurrent use, so calls are serialized through mu.
+type Detector struct {
+	model    *tensorflow.SavedModel
+	inputOp  string
+	outputOp string
+
+	mu sync.Mutex
+}
+
+// NewDetector loads a SavedModel NSFW classifier from modelDir under the
+// "serve" tag.
+func NewDetector(modelDir string) (*Detector, error) {
+	model, err := tensorflow.LoadSavedModel(modelDir, []string{"serve"}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Detector{model: model, inputOp: "input_tensor", outputOp: "predictions"}, nil
+}
+
+// Labels decodes an image (any format imgutil decodes: JPEG, PNG, GIF, BMP
+// or WebP), resizes it to 224x224 and returns a score
+// per NSFW category, sorted by descending probability.
+func (d *Detector) Labels(img []byte) (Labels, error) {
+	tensor, err := normalize(img)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	output, err := d.model.Session.Run(
+		map[tensorflow.Output]*tensorflow.Tensor{
+			d.model.Graph.Operation(d.inputOp).Output(0): tensor,
+		},
+		[]tensorflow.Output{
+			d.model.Graph.Operation(d.outputOp).Output(0),
+		},
+		nil)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := output[0].Value().([][]float32)[0]
+	labels := make(Labels, 0, len(categories))
+	for i, category := range categories {
+		if i >= len(scores) {
+			break
+		}
+		labels = append(labels, Label{Label: category, Probability: scores[i]})
+	}
+
+	sort.Sort(labels)
+	return labels, nil
+}
+
+// normalize decodes an image via imgutil and resizes it to inputSize,
+// returning a [1,224,224,3] float32 tensor scaled to [0,1].
+func normalize(img []byte) (*tensorflow.Tensor, error) {
+	pixels, err := imgutil.DecodeAndResize(img, inputSize)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := make([][][][]float32, 1)
+	batch[0] = make([][][]float32, len(pixels))
+	for y, row := range pixels {
+		batch[0][y] = make([][]float32, len(row))
+		for x, px := range row {
+			batch[0][y][x] = []float32{px[0] / 255, px[1] / 255, px[2] / 255}
+		}
+	}
+
+	return tensorflow.NewTensor(batch)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/fedproject/go_img_recognition/imgutil"
+
+	tensorflow "github.com/tensorflow/tensorflow/tensorflow/go"
+	"github.com/tensorflow/tensorflow/tensorflow/go/op"
+)
+
+// Preprocessor decodes an image in whatever format it arrives in and
+// normalizes it into the tensor shape a Classifier's model expects, so the
+// CLI and the HTTP handler can share the exact same code path.
+type Preprocessor struct {
+	config ModelConfig
+}
+
+// NewPreprocessor builds a Preprocessor for the given model config.
+func NewPreprocessor(config ModelConfig) *Preprocessor {
+	return &Preprocessor{config: config}
+}
+
+// Normalize decodes r (JPEG, PNG, GIF, BMP or WebP) and resizes it to the
+// model's input size, returning a [1,H,W,3] float32 tensor. JPEG/PNG/GIF
+// are decoded and resized inside a TensorFlow graph; anything else falls
+// back to a pure-Go decode + resize.
+func (p *Preprocessor) Normalize(r io.Reader) (*tensorflow.Tensor, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch sniffFormat(raw) {
+	case "image/jpeg", "image/png", "image/gif":
+		return p.normalizeInGraph(raw)
+	default:
+		return p.normalizeGo(raw)
+	}
+}
+
+// NormalizeBatch decodes and normalizes each image independently, then
+// stacks the results into a single [N,H,W,3] tensor for one batched
+// session.Run.
+func (p *Preprocessor) NormalizeBatch(images []io.Reader) (*tensorflow.Tensor, error) {
+	batch := make([][][][]float32, len(images))
+	for i, r := range images {
+		tensor, err := p.Normalize(r)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %v", i, err)
+		}
+		batch[i] = tensor.Value().([][][][]float32)[0]
+	}
+	return tensorflow.NewTensor(batch)
+}
+
+// sniffFormat inspects magic bytes to tell apart the formats this package
+// understands, falling back to the empty string for anything unrecognized.
+func sniffFormat(raw []byte) string {
+	switch {
+	case bytes.HasPrefix(raw, []byte("\xFF\xD8\xFF")):
+		return "image/jpeg"
+	case bytes.HasPrefix(raw, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(raw, []byte("GIF87a")), bytes.HasPrefix(raw, []byte("GIF89a")):
+		return "image/gif"
+	case bytes.HasPrefix(raw, []byte("BM")):
+		return "image/bmp"
+	case len(raw) >= 12 && bytes.HasPrefix(raw, []byte("RIFF")) && bytes.Equal(raw[8:12], []byte("WEBP")):
+		return "image/webp"
+	default:
+		return ""
+	}
+}
+
+// normalizeInGraph decodes, resizes and normalizes raw inside a TensorFlow
+// graph built for the given config.
+func (p *Preprocessor) normalizeInGraph(raw []byte) (*tensorflow.Tensor, error) {
+	tensor, err := tensorflow.NewTensor(string(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	graph, input, output, err := getNormalizedGraph(p.config, sniffFormat(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := tensorflow.NewSession(graph, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	normalized, err := session.Run(
+		map[tensorflow.Output]*tensorflow.Tensor{
+			input: tensor,
+		},
+		[]tensorflow.Output{
+			output,
+		},
+		nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return normalized[0], nil
+}
+
+// getNormalizedGraph builds a graph that decodes (according to format),
+// resizes and normalizes an image according to config (input size, mean,
+// scale).
+func getNormalizedGraph(config ModelConfig, format string) (graph *tensorflow.Graph, input, output tensorflow.Output, err error) {
+	s := op.NewScope()
+	input = op.Placeholder(s, tensorflow.String)
+
+	var batched tensorflow.Output // 4D float [batch, H, W, 3]
+	switch format {
+	case "image/jpeg":
+		decode := op.DecodeJpeg(s, input, op.DecodeJpegChannels(3))
+		batched = toBatch(s, decode)
+	case "image/png":
+		decode := op.DecodePng(s, input, op.DecodePngChannels(3))
+		batched = toBatch(s, decode)
+	case "image/gif":
+		// DecodeGif always returns [num_frames, H, W, 3]; keep the first frame.
+		batched = firstFrame(s, op.DecodeGif(s, input))
+	default:
+		err = fmt.Errorf("unsupported format for graph decode: %q", format)
+		return
+	}
+
+	resized := op.ResizeBilinear(s, batched,
+		op.Const(s.SubScope("size"), []int32{int32(config.InputSize), int32(config.InputSize)}))
+
+	scale := config.Scale
+	if scale == 0 {
+		scale = 1
+	}
+
+	// Sub/Div: (x - mean) / scale, element-wise
+	output = op.Div(s,
+		op.Sub(s, resized, op.Const(s.SubScope("mean"), config.Mean)),
+		op.Const(s.SubScope("scale"), scale))
+	graph, err = s.Finalize()
+
+	return graph, input, output, err
+}
+
+// toBatch inserts a leading batch dimension of 1 into a decoded HWC image.
+func toBatch(s *op.Scope, decoded tensorflow.Output) tensorflow.Output {
+	return op.ExpandDims(s,
+		op.Cast(s, decoded, tensorflow.Float),
+		op.Const(s.SubScope("make_batch"), int32(0)))
+}
+
+// firstFrame slices out frame 0 of a decoded [frames, H, W, C] image,
+// keeping the leading batch dimension.
+func firstFrame(s *op.Scope, decoded tensorflow.Output) tensorflow.Output {
+	return op.Slice(s,
+		op.Cast(s, decoded, tensorflow.Float),
+		op.Const(s.SubScope("begin"), []int32{0, 0, 0, 0}),
+		op.Const(s.SubScope("size"), []int32{1, -1, -1, -1}))
+}
+
+// normalizeGo decodes raw in pure Go (BMP, WebP, or anything else the
+// graph path doesn't cover) via imgutil, which also corrects EXIF
+// orientation and resizes to the model's input size.
+func (p *Preprocessor) normalizeGo(raw []byte) (*tensorflow.Tensor, error) {
+	pixels, err := imgutil.DecodeAndResize(raw, p.config.InputSize)
+	if err != nil {
+		return nil, err
+	}
+
+	scale := p.config.Scale
+	if scale == 0 {
+		scale = 1
+	}
+
+	batch := make([][][][]float32, 1)
+	batch[0] = make([][][]float32, len(pixels))
+	for y, row := range pixels {
+		batch[0][y] = make([][]float32, len(row))
+		for x, px := range row {
+			batch[0][y][x] = []float32{
+				(px[0] - p.config.Mean) / scale,
+				(px[1] - p.config.Mean) / scale,
+				(px[2] - p.config.Mean) / scale,
+			}
+		}
+	}
+
+	return tensorflow.NewTensor(batch)
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestSniffFormat(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  []byte
+		want string
+	}{
+		{"jpeg", []byte("\xFF\xD8\xFF\xE0rest"), "image/jpeg"},
+		{"png", []byte("\x89PNG\r\n\x1a\nrest"), "image/png"},
+		{"gif87", []byte("GIF87arest"), "image/gif"},
+		{"gif89", []byte("GIF89arest"), "image/gif"},
+		{"bmp", []byte("BMrest"), "image/bmp"},
+		{"webp", append([]byte("RIFF\x00\x00\x00\x00WEBP"), "rest"...), "image/webp"},
+		{"unknown", []byte("not an image"), ""},
+		{"too short for webp check", []byte("RIFF"), ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := sniffFormat(c.raw); got != c.want {
+				t.Errorf("sniffFormat(%q) = %q, want %q", c.raw, got, c.want)
+			}
+		})
+	}
+}
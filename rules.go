@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// LabelRule describes how a single raw model label should be treated:
+// dropped below Threshold, renamed to a canonical label via See, and
+// grouped into Categories (e.g. "animal", "vehicle", "food"). When two raw
+// labels alias to the same See label, Priority breaks the tie over which
+// rule's Categories win (ties within the same Priority fall back to
+// whichever has the higher probability).
+type LabelRule struct {
+	Threshold  float32  `yaml:"threshold"`
+	Priority   int      `yaml:"priority"`
+	Categories []string `yaml:"categories"`
+	See        string   `yaml:"see"`
+}
+
+// LabelRules maps a raw model label (as it appears in the labels file) to
+// the rule that governs it.
+type LabelRules map[string]LabelRule
+
+// LoadLabelRules reads a YAML rules file (e.g. labels.yml) mapping raw
+// model labels to LabelRule entries.
+func LoadLabelRules(path string) (LabelRules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules LabelRules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Classify applies rules to an already-sorted list of labels: labels below
+// their rule's threshold are dropped, aliases are resolved via See, and
+// Categories are attached. Labels with no matching rule pass through
+// unchanged. When several raw labels alias to the same See label, they
+// are merged into a single entry, keeping the highest-Priority rule's
+// Categories (ties broken by probability). The result is re-sorted by
+// probability.
+func (rules LabelRules) Classify(labels []Label) []Label {
+	if len(rules) == 0 {
+		return labels
+	}
+
+	var order []string
+	best := make(map[string]Label)
+	bestPriority := make(map[string]int)
+
+	for _, l := range labels {
+		rule, ok := rules[l.Label]
+		if !ok {
+			if _, seen := best[l.Label]; !seen {
+				order = append(order, l.Label)
+			}
+			best[l.Label] = l
+			continue
+		}
+		if l.Probability < rule.Threshold {
+			continue
+		}
+
+		canonical := l.Label
+		if rule.See != "" {
+			canonical = rule.See
+		}
+		l.Label = canonical
+		l.Categories = rule.Categories
+
+		existing, seen := best[canonical]
+		if !seen {
+			order = append(order, canonical)
+			best[canonical] = l
+			bestPriority[canonical] = rule.Priority
+			continue
+		}
+		if rule.Priority > bestPriority[canonical] ||
+			(rule.Priority == bestPriority[canonical] && l.Probability > existing.Probability) {
+			best[canonical] = l
+			bestPriority[canonical] = rule.Priority
+		}
+	}
+
+	result := make([]Label, 0, len(order))
+	for _, label := range order {
+		result = append(result, best[label])
+	}
+
+	sort.Sort(Labels(result))
+	return result
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabelRulesClassify(t *testing.T) {
+	rules := LabelRules{
+		"tabby":        {Threshold: 0.1, Priority: 1, Categories: []string{"animal", "feline"}, See: "cat"},
+		"egyptian_cat": {Threshold: 0.1, Priority: 2, Categories: []string{"animal", "pet"}, See: "cat"},
+		"siamese_cat":  {Threshold: 0.9, Priority: 3, Categories: []string{"animal", "exotic"}, See: "cat"},
+		"sedan":        {Threshold: 0.1, Categories: []string{"vehicle"}},
+	}
+
+	labels := []Label{
+		{Label: "tabby", Probability: 0.6},
+		{Label: "egyptian_cat", Probability: 0.5},
+		{Label: "siamese_cat", Probability: 0.95}, // clears its 0.9 threshold and has the highest priority
+		{Label: "sedan", Probability: 0.4},
+		{Label: "balloon", Probability: 0.2}, // no rule: passes through unchanged
+	}
+
+	got := rules.Classify(labels)
+
+	want := []Label{
+		{Label: "cat", Probability: 0.95, Categories: []string{"animal", "exotic"}},
+		{Label: "sedan", Probability: 0.4, Categories: []string{"vehicle"}},
+		{Label: "balloon", Probability: 0.2},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Classify() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLabelRulesClassifyDropsBelowThreshold(t *testing.T) {
+	rules := LabelRules{
+		"tabby": {Threshold: 0.5, Categories: []string{"animal"}, See: "cat"},
+	}
+
+	got := rules.Classify([]Label{{Label: "tabby", Probability: 0.1}})
+	if len(got) != 0 {
+		t.Fatalf("Classify() = %+v, want no labels", got)
+	}
+}
+
+func TestLabelRulesClassifyTieBrokenByProbability(t *testing.T) {
+	rules := LabelRules{
+		"tabby":        {Threshold: 0.1, Priority: 1, Categories: []string{"a"}, See: "cat"},
+		"egyptian_cat": {Threshold: 0.1, Priority: 1, Categories: []string{"b"}, See: "cat"},
+	}
+
+	got := rules.Classify([]Label{
+		{Label: "tabby", Probability: 0.3},
+		{Label: "egyptian_cat", Probability: 0.7},
+	})
+
+	want := []Label{{Label: "cat", Probability: 0.7, Categories: []string{"b"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Classify() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLabelRulesClassifyEmptyRulesPassesThrough(t *testing.T) {
+	labels := []Label{{Label: "tabby", Probability: 0.3}}
+	got := LabelRules{}.Classify(labels)
+	if !reflect.DeepEqual(got, labels) {
+		t.Fatalf("Classify() = %+v, want %+v", got, labels)
+	}
+}
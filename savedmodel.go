@@ -0,0 +1,74 @@
+package main
+
+import (
+	"sync"
+
+	tensorflow "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// savedModelClassifier runs inference against a TensorFlow SavedModel
+// directory, e.g. NASNet-Mobile or MobileNet exported with modelTags like
+// []string{"serve"}. session.Run is not safe for concurrent use, so calls
+// are serialized through mu.
+type savedModelClassifier struct {
+	model        *tensorflow.SavedModel
+	labels       []string
+	config       ModelConfig
+	preprocessor *Preprocessor
+
+	mu sync.Mutex
+}
+
+// NewSavedModelClassifier loads a SavedModel from modelDir under
+// config.Tags (defaulting to "serve" when unset).
+func NewSavedModelClassifier(modelDir, labelsFile string, config ModelConfig) (*savedModelClassifier, error) {
+	tags := config.Tags
+	if len(tags) == 0 {
+		tags = []string{"serve"}
+	}
+
+	model, err := tensorflow.LoadSavedModel(modelDir, tags, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := loadLabels(labelsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &savedModelClassifier{
+		model:        model,
+		labels:       labels,
+		config:       config,
+		preprocessor: NewPreprocessor(config),
+	}, nil
+}
+
+func (c *savedModelClassifier) Labels(img []byte) ([]Label, error) {
+	return classifyOne(c, c.preprocessor, c.labels, img)
+}
+
+// ClassifyBatch stacks all images into a single [N,H,W,3] tensor and runs
+// inference once, instead of once per image.
+func (c *savedModelClassifier) ClassifyBatch(images [][]byte) ([][]Label, error) {
+	return classifyBatch(c, c.preprocessor, c.labels, images)
+}
+
+func (c *savedModelClassifier) run(tensor *tensorflow.Tensor) (*tensorflow.Tensor, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	output, err := c.model.Session.Run(
+		map[tensorflow.Output]*tensorflow.Tensor{
+			c.model.Graph.Operation(c.config.InputOp).Output(0): tensor,
+		},
+		[]tensorflow.Output{
+			c.model.Graph.Operation(c.config.OutputOp).Output(0),
+		},
+		nil)
+	if err != nil {
+		return nil, err
+	}
+	return output[0], nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fedproject/go_img_recognition/nsfw"
+)
+
+const defaultTopN = 5
+
+// Server wraps an already-loaded Classifier, its label rules and an
+// optional NSFW detector for reuse across HTTP requests.
+type Server struct {
+	classifier Classifier
+	rules      LabelRules
+	nsfw       *nsfw.Detector
+}
+
+// NewServer wraps an already-loaded Classifier, LabelRules and optional
+// NSFW detector (nil disables NSFW scoring) for reuse across HTTP
+// requests.
+func NewServer(classifier Classifier, rules LabelRules, nsfwDetector *nsfw.Detector) *Server {
+	return &Server{classifier: classifier, rules: rules, nsfw: nsfwDetector}
+}
+
+type recognizeRequest struct {
+	URL string `json:"url"`
+}
+
+type recognizeResponse struct {
+	Labels []Label     `json:"labels"`
+	NSFW   nsfw.Labels `json:"nsfw,omitempty"`
+}
+
+// handleRecognize accepts either a multipart "image" file upload or a JSON
+// body with an image URL, runs inference and returns the top-N labels.
+func (s *Server) handleRecognize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	topN := defaultTopN
+	if v := r.URL.Query().Get("topN"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid topN", http.StatusBadRequest)
+			return
+		}
+		topN = n
+	}
+
+	img, err := s.readImage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		wg         sync.WaitGroup
+		labels     []Label
+		labelsErr  error
+		nsfwLabels nsfw.Labels
+		nsfwErr    error
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		labels, labelsErr = s.classifier.Labels(img)
+	}()
+
+	if s.nsfw != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nsfwLabels, nsfwErr = s.nsfw.Labels(img)
+		}()
+	}
+
+	wg.Wait()
+
+	if labelsErr != nil {
+		http.Error(w, fmt.Sprintf("could not run inference: %v", labelsErr), http.StatusInternalServerError)
+		return
+	}
+	if nsfwErr != nil {
+		http.Error(w, fmt.Sprintf("could not run nsfw inference: %v", nsfwErr), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recognizeResponse{
+		Labels: topLabels(s.rules.Classify(labels), topN),
+		NSFW:   nsfwLabels,
+	})
+}
+
+// readImage returns the raw image bytes for either a multipart "image"
+// upload or a JSON {"url": "..."} body.
+func (s *Server) readImage(r *http.Request) ([]byte, error) {
+	contentType := r.Header.Get("Content-Type")
+
+	if strings.HasPrefix(contentType, "multipart/") {
+		file, _, err := r.FormFile("image")
+		if err != nil {
+			return nil, fmt.Errorf("missing \"image\" file: %v", err)
+		}
+		defer file.Close()
+		return ioutil.ReadAll(file)
+	}
+
+	var body recognizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid request body: %v", err)
+	}
+	if body.URL == "" {
+		return nil, fmt.Errorf("missing image url")
+	}
+
+	imageURL, err := validateImageURL(body.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := imageFetchClient.Get(imageURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("unable to get image from url: %v", err)
+	}
+	defer resp.Body.Close()
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}